@@ -0,0 +1,100 @@
+"""Listing scroll loop and search chunks of OzonParser, no browser needed"""
+import os
+import unittest
+
+from ozon_parser import DeadlineExceeded, OzonParser, SearchOptions
+
+FIXTURES = os.path.join(os.path.dirname(__file__), 'fixtures')
+
+
+class FakeMouse:
+    def wheel(self, dx, dy):
+        pass
+
+
+class FakeLocator:
+    def count(self):
+        return 0
+
+
+class FakePage:
+    """Page that always shows the same saved listing"""
+
+    def __init__(self, html: str):
+        self.html = html
+        self.mouse = FakeMouse()
+
+    def content(self) -> str:
+        return self.html
+
+    def locator(self, selector: str) -> FakeLocator:
+        return FakeLocator()
+
+
+class ScrollCardsTest(unittest.TestCase):
+    def setUp(self):
+        with open(os.path.join(FIXTURES, 'search_filtered.html'), encoding='utf-8') as f:
+            self.page = FakePage(f.read())
+        self.ozon = OzonParser(global_dedup=True, sleep=lambda seconds: None)
+
+    def ids(self, max_products: int, options: SearchOptions = None) -> list[str]:
+        keep = options.accepts if options and options.has_filters() else None
+        return [product['id'] for product in self.ozon._scroll_cards(self.page, max_products, keep=keep)]
+
+    def test_rejected_cards_stay_unseen(self):
+        # A filtered call must not use up the cards its filter dropped
+        options = SearchOptions(min_price=500, max_price=1500)
+        self.assertEqual(self.ids(0, options), ['111111111', '222222222', '444444444', '666666666'])
+        self.assertEqual(self.ozon.seen, {'111111111', '222222222', '444444444', '666666666'})
+        self.assertEqual(self.ids(0), ['333333333', '555555555'])
+
+    def test_surplus_stays_unseen(self):
+        options = SearchOptions(min_price=500, max_price=1500)
+        self.assertEqual(self.ids(1, options), ['111111111'])
+        self.assertEqual(self.ids(2, options), ['222222222', '444444444'])
+        self.assertEqual(self.ids(0), ['333333333', '555555555', '666666666'])
+
+    def test_exclude_is_skipped_but_not_marked_seen(self):
+        products = self.ozon._scroll_cards(self.page, 0, exclude={'111111111', '222222222'})
+        self.assertEqual([product['id'] for product in products],
+                         ['333333333', '444444444', '555555555', '666666666'])
+        self.assertNotIn('111111111', self.ozon.seen)
+
+
+class SearchDeadlineTest(unittest.TestCase):
+    """A chunk spanning pages keeps earlier pages when time runs out"""
+
+    def setUp(self):
+        self.ozon = OzonParser(sleep=lambda seconds: None)
+
+    def search_with(self, late_partial):
+        def search_page(query, options, max_products, cancel, seen):
+            if options.page == 1:
+                return {'query': query, 'count': 2, 'total': 87, 'fetched_at': '2024-01-01T00:00:00+00:00',
+                        'elapsed_ms': 10, 'products': [{'id': '1'}, {'id': '2'}]}
+            raise DeadlineExceeded('Out of time', late_partial)
+
+        self.ozon._search_page = search_page
+        with self.assertRaises(DeadlineExceeded) as caught:
+            self.ozon.search('наушники', 5)
+        return caught.exception.partial
+
+    def test_cases(self):
+        cases = [
+            # (name, partial of the page that ran out of time, expected IDs)
+            ('while loading the page', None, ['1', '2']),
+            ('while scrolling the page', {'query': 'наушники', 'count': 1, 'products': [{'id': '3'}],
+                                          'fetched_at': '2024-01-01T00:00:05+00:00', 'elapsed_ms': 5},
+             ['1', '2', '3']),
+        ]
+        for name, late_partial, expected in cases:
+            with self.subTest(name):
+                partial = self.search_with(late_partial)
+                self.assertEqual([product['id'] for product in partial['products']], expected)
+                self.assertEqual(partial['count'], len(expected))
+                self.assertEqual(partial['total'], 87)
+                self.assertEqual(partial['fetched_at'], '2024-01-01T00:00:00+00:00')
+
+
+if __name__ == '__main__':
+    unittest.main()
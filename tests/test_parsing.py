@@ -0,0 +1,385 @@
+"""Parsing of saved Ozon pages, no browser needed
+
+Run from the repository root with: python -m unittest discover tests
+"""
+import os
+import unittest
+from urllib.parse import parse_qs, urlsplit
+
+from bs4 import BeautifulSoup
+
+from ozon_parser import (PAGE_FIELDS, NoProductsError, SearchOptions, Selectors, build_search_url,
+                         collect_cards, is_price_line, listing_limit, parse_price,
+                         parse_product_html, parse_search_html, search_from_html, text_lines)
+
+FIXTURES = os.path.join(os.path.dirname(__file__), 'fixtures')
+PRODUCT_URL = 'https://www.ozon.ru/product/noski-muzhskie-10-par-123456789/'
+
+
+def fixture(name: str) -> str:
+    with open(os.path.join(FIXTURES, name), encoding='utf-8') as f:
+        return f.read()
+
+
+class ParsePriceTest(unittest.TestCase):
+    def test_cases(self):
+        cases = [
+            # (text, expected)
+            ('499 ₽', 499),
+            ('12 990 ₽', 12990),
+            ('12\u00a0990\u00a0₽', 12990),
+            ('12\u202f990\u202f₽', 12990),
+            ('1\u00a0234\u202f567 ₽', 1234567),
+            ('от 1 299 ₽', 1299),
+            ('До 2\u00a0500 ₽', 2500),
+            ('от1 299 ₽', 1299),
+            ('1 299,50 ₽', 1299),
+            ('от 1\u202f299,99 ₽', 1299),
+            ('  990 ₽  ', 990),
+            ('0 ₽', 0),
+            ('', None),
+            ('₽', None),
+            ('Цена не указана', None),
+            ('от ₽', None),
+        ]
+        for text, expected in cases:
+            with self.subTest(text=text):
+                self.assertEqual(parse_price(text), expected)
+
+
+class TextLinesTest(unittest.TestCase):
+    def test_cases(self):
+        cases = [
+            # (html, expected lines)
+            ('<div><span>12\u00a0990\u00a0₽</span><span>1\u202f299 ₽</span></div>',
+             ['12 990 ₽', '1 299 ₽']),
+            ('<div><span>  Носки   мужские\t10 пар </span></div>', ['Носки мужские 10 пар']),
+            ('<div><span>Подарочная карта 500\u00a0₽</span></div>', ['Подарочная карта 500 ₽']),
+            ('<div><span> </span><span>\u00a0</span><span>\u202f</span></div>', []),
+            ('<div>Доставка<br>завтра</div>', ['Доставка', 'завтра']),
+        ]
+        for html, expected in cases:
+            with self.subTest(html=html):
+                el = BeautifulSoup(html, 'html.parser').select_one('div')
+                self.assertEqual(text_lines(el), expected)
+
+
+class IsPriceLineTest(unittest.TestCase):
+    def test_cases(self):
+        cases = [
+            # (line, currency, expected)
+            ('499 ₽', '₽', True),
+            ('12 990 ₽', '₽', True),
+            ('1 299,50 ₽', '₽', True),
+            ('от 1 299 ₽', '₽', True),
+            ('до 2 500 ₽', '₽', True),
+            ('12990₽', '₽', True),
+            ('12\u00a0990\u00a0₽', '₽', True),
+            ('12\u202f990\u202f₽', '₽', True),
+            ('Подарочная карта 500\u00a0₽', '₽', False),
+            ('12 990 ₸', '₸', True),
+            ('12 990 ₽', '₸', False),
+            ('Подарочная карта 500 ₽', '₽', False),
+            ('Скидка 500 ₽ на первый заказ', '₽', False),
+            ('500 ₽ за штуку', '₽', False),
+            ('−50%', '₽', False),
+            ('₽', '₽', False),
+            ('', '₽', False),
+        ]
+        for line, currency, expected in cases:
+            with self.subTest(line=line, currency=currency):
+                self.assertIs(is_price_line(line, currency), expected)
+
+    def test_special_spaces(self):
+        # is_price_line expects lines from text_lines, which normalizes the
+        # spaces Ozon puts in prices
+        for line in ('12\u00a0990\u00a0₽', '12\u202f990\u202f₽', 'от\u00a01\u202f299,50\u00a0₽'):
+            with self.subTest(line=line):
+                el = BeautifulSoup(f'<div>{line}</div>', 'html.parser').select_one('div')
+                [normalized] = text_lines(el)
+                self.assertTrue(is_price_line(normalized))
+
+
+class ParseSearchHtmlTest(unittest.TestCase):
+    def setUp(self):
+        self.html = fixture('search.html')
+
+    def test_cards(self):
+        products = {product['id']: product for product in parse_search_html(self.html)}
+        self.assertEqual(list(products), ['123456789', '987654321', '555555555'])
+
+        cases = [
+            # (product ID, field, expected)
+            ('123456789', 'name', 'Носки мужские хлопковые 10 пар'),
+            ('123456789', 'price', '499 ₽'),
+            ('123456789', 'old_price', '999 ₽'),
+            ('123456789', 'price_value', 499),
+            ('123456789', 'old_price_value', 999),
+            ('123456789', 'image', 'https://cdn1.ozone.ru/s3/multimedia-1/wc250/6601.jpg'),
+            ('123456789', 'link', 'https://www.ozon.ru/product/noski-muzhskie-10-par-123456789/?asb=1'),
+            ('123456789', 'rating', 4.8),
+            ('123456789', 'reviews', 1234),
+            ('123456789', 'delivery', 'Доставка завтра'),
+            ('123456789', 'badges', ['Бестселлер']),
+            ('123456789', 'express', False),
+            ('987654321', 'name', 'Футболка хлопковая оверсайз'),
+            ('987654321', 'price_value', 1299),
+            ('987654321', 'old_price_value', 1999),
+            ('987654321', 'rating', 4.9),
+            ('987654321', 'reviews', 12),
+            ('987654321', 'delivery', ''),
+            ('987654321', 'express', True),
+            ('555555555', 'name', 'Подарочная карта 500 ₽'),
+            ('555555555', 'price', '500 ₽'),
+            ('555555555', 'old_price', ''),
+            ('555555555', 'old_price_value', None),
+            ('555555555', 'image', ''),
+            ('555555555', 'rating', None),
+            ('555555555', 'reviews', None),
+            ('555555555', 'delivery', '18 октября'),
+        ]
+        for product_id, field, expected in cases:
+            with self.subTest(product_id=product_id, field=field):
+                self.assertEqual(products[product_id][field], expected)
+
+    def test_limit_and_seen(self):
+        cases = [
+            # (limit, seen, expected IDs)
+            (None, None, ['123456789', '987654321', '555555555']),
+            (2, None, ['123456789', '987654321']),
+            (0, None, []),
+            (None, {'123456789'}, ['987654321', '555555555']),
+            (1, {'987654321'}, ['123456789']),
+        ]
+        for limit, seen, expected in cases:
+            with self.subTest(limit=limit, seen=seen):
+                products = parse_search_html(self.html, limit, set(seen) if seen else None)
+                self.assertEqual([product['id'] for product in products], expected)
+
+    def test_seen_is_updated(self):
+        seen = set()
+        parse_search_html(self.html, seen=seen)
+        self.assertEqual(seen, {'123456789', '987654321', '555555555'})
+        self.assertEqual(parse_search_html(self.html, seen=seen), [])
+
+    def test_custom_listing_selector(self):
+        selectors = Selectors(listing='.tile-root a[href*="/product/futbolka"]')
+        products = parse_search_html(self.html, selectors=selectors)
+        self.assertEqual([product['id'] for product in products], ['987654321'])
+
+
+class ListingLimitTest(unittest.TestCase):
+    def test_limits(self):
+        cases = [
+            # (max_products, expected limit)
+            (0, None),
+            (1, 1),
+            (36, 36),
+        ]
+        for max_products, expected in cases:
+            with self.subTest(max_products=max_products):
+                self.assertEqual(listing_limit(max_products), expected)
+
+    def test_negative(self):
+        for max_products in (-1, -100):
+            with self.subTest(max_products=max_products):
+                with self.assertRaises(ValueError):
+                    listing_limit(max_products)
+
+
+class SearchFromHtmlTest(unittest.TestCase):
+    def setUp(self):
+        self.html = fixture('search.html')
+
+    def test_max_products(self):
+        cases = [
+            # (max_products, expected count), 0 means no limit
+            (0, 3),
+            (1, 1),
+            (2, 2),
+            (10, 3),
+        ]
+        for max_products, expected in cases:
+            with self.subTest(max_products=max_products):
+                result = search_from_html(self.html, 'носки', max_products)
+                self.assertEqual(result['count'], expected)
+                self.assertEqual(len(result['products']), expected)
+                self.assertEqual(result['query'], 'носки')
+                self.assertEqual(result['total'], 1234)
+
+    def test_negative_max_products(self):
+        with self.assertRaises(ValueError):
+            search_from_html(self.html, 'носки', -1)
+
+    def test_no_products(self):
+        with self.assertRaises(NoProductsError):
+            search_from_html('<html><body>Ничего не нашлось</body></html>', 'носки', 0)
+
+
+class PriceFilterTest(unittest.TestCase):
+    def test_currency_price(self):
+        cases = [
+            # (min_price, max_price, expected currency_price, None when absent)
+            (500, 1500, '500.000;1500.000'),
+            (500, None, '500.000;'),
+            (None, 1500, '0.000;1500.000'),
+            (0, 0, '0.000;0.000'),
+            (None, None, None),
+        ]
+        for min_price, max_price, expected in cases:
+            with self.subTest(min_price=min_price, max_price=max_price):
+                url = build_search_url('наушники', SearchOptions(min_price=min_price, max_price=max_price))
+                params = parse_qs(urlsplit(url).query)
+                self.assertEqual(params.get('currency_price', [None])[0], expected)
+                self.assertEqual(params['text'], ['наушники'])
+
+    def test_currency_price_encoding(self):
+        url = build_search_url('наушники', SearchOptions(min_price=500, max_price=1500))
+        self.assertIn('currency_price=500.000%3B1500.000', url)
+
+    def test_invalid_range(self):
+        cases = [
+            (-1, None),
+            (None, -1),
+            (1500, 500),
+        ]
+        for min_price, max_price in cases:
+            with self.subTest(min_price=min_price, max_price=max_price):
+                with self.assertRaises(ValueError):
+                    build_search_url('наушники', SearchOptions(min_price=min_price, max_price=max_price))
+
+    def test_cards_outside_range_are_dropped(self):
+        # Ozon mixes promoted products into a filtered listing regardless
+        # of their price; cards without a price are kept
+        products = parse_search_html(fixture('search_filtered.html'))
+        self.assertEqual(len(products), 6)
+
+        cases = [
+            # (min_price, max_price, expected IDs)
+            (500, 1500, ['111111111', '222222222', '444444444', '666666666']),
+            (501, 1499, ['111111111', '666666666']),
+            (None, 999, ['111111111', '222222222', '555555555', '666666666']),
+            (10000, None, ['333333333', '666666666']),
+            (None, None, ['111111111', '222222222', '333333333', '444444444', '555555555', '666666666']),
+        ]
+        for min_price, max_price, expected in cases:
+            with self.subTest(min_price=min_price, max_price=max_price):
+                options = SearchOptions(min_price=min_price, max_price=max_price)
+                self.assertEqual(options.has_filters(), min_price is not None or max_price is not None)
+                kept = [product['id'] for product in products if options.accepts(product)]
+                self.assertEqual(kept, expected)
+
+
+class ParseProductHtmlTest(unittest.TestCase):
+    def setUp(self):
+        self.product = parse_product_html(fixture('product.html'), PRODUCT_URL)
+
+    def test_fields(self):
+        cases = [
+            ('id', '123456789'),
+            ('url', PRODUCT_URL),
+            ('name', 'Носки мужские хлопковые 10 пар'),
+            ('price', '549 ₽'),
+            ('price_value', 549),
+            ('card_price', '499 ₽'),
+            ('card_price_value', 499),
+            ('old_price', '999 ₽'),
+            ('old_price_value', 999),
+            ('image', 'https://cdn1.ozone.ru/s3/multimedia-1/6601.jpg'),
+            ('images', ['https://cdn1.ozone.ru/s3/multimedia-1/6601.jpg',
+                        'https://cdn1.ozone.ru/s3/multimedia-1/6602.jpg']),
+            ('rating', 4.8),
+            ('reviews', 1234),
+            ('seller', 'SocksLand'),
+            ('seller_rating', 4.7),
+            ('characteristics', {'Бренд': 'SocksLand', 'Состав': 'Хлопок 80%, полиамид 20%'}),
+            ('brand', 'SocksLand'),
+            # The sold-out text in the script must not count
+            ('in_stock', True),
+            ('availability', 'in_stock'),
+        ]
+        for field, expected in cases:
+            with self.subTest(field=field):
+                self.assertEqual(self.product[field], expected)
+
+    def test_missing_widgets(self):
+        html = '<html><body><h1>Носки</h1><p>Этот товар закончился</p></body></html>'
+        product = parse_product_html(html, PRODUCT_URL)
+        cases = [
+            ('name', 'Носки'),
+            ('in_stock', False),
+            ('availability', 'out_of_stock'),
+            ('brand', ''),
+            ('images', []),
+            ('image', ''),
+            ('rating', None),
+            ('reviews', None),
+            ('price', ''),
+            ('price_value', None),
+            ('old_price', ''),
+            ('card_price', ''),
+            ('card_price_value', None),
+            ('pricing_tiers', []),
+        ]
+        for field, expected in cases:
+            with self.subTest(field=field):
+                self.assertEqual(product[field], expected)
+        for field in ('seller', 'seller_rating', 'variants', 'characteristics'):
+            with self.subTest(field=field):
+                self.assertNotIn(field, product)
+
+    def test_page_fields_always_set(self):
+        pages = [
+            ('full page', fixture('product.html')),
+            ('empty page', '<html><body></body></html>'),
+        ]
+        for name, html in pages:
+            product = parse_product_html(html, PRODUCT_URL)
+            for field in PAGE_FIELDS:
+                with self.subTest(name, field=field):
+                    self.assertIn(field, product)
+
+
+class CollectCardsTest(unittest.TestCase):
+    def links(self, html: str) -> list:
+        return BeautifulSoup(html, 'html.parser').select('a[href*="/product/"]')
+
+    def test_cases(self):
+        cases = [
+            # (name, html, limit, seen, expected (id, name, price) tuples)
+            ('picture link merged with title link',
+             '<div class="tile-root"><a href="/product/a-1/"><img src="1.jpg"></a>'
+             '<a href="/product/a-1/"><span>Носки мужские 10 пар</span><span>499 ₽</span></a></div>',
+             None, None, [('1', 'Носки мужские 10 пар', '499 ₽')]),
+            ('links without an ID are skipped',
+             '<a href="/product/"><span>Без идентификатора</span></a>'
+             '<a href="/product/b-2/"><span>Футболка хлопковая</span><span>990 ₽</span></a>',
+             None, None, [('2', 'Футболка хлопковая', '990 ₽')]),
+            ('seen products are skipped',
+             '<a href="/product/a-1/"><span>Носки мужские 10 пар</span></a>'
+             '<a href="/product/b-2/"><span>Футболка хлопковая</span></a>',
+             None, {'1'}, [('2', 'Футболка хлопковая', '')]),
+            ('limit counts products, not links',
+             '<a href="/product/a-1/"><img src="1.jpg"></a><a href="/product/a-1/"><span>Носки мужские 10 пар</span></a>'
+             '<a href="/product/b-2/"><span>Футболка хлопковая</span></a>',
+             1, None, [('1', 'Носки мужские 10 пар', '')]),
+            ('no links',
+             '<p>Ничего не нашлось</p>',
+             None, None, []),
+        ]
+        for name, html, limit, seen, expected in cases:
+            with self.subTest(name):
+                products = collect_cards(self.links(html), limit, seen)
+                self.assertEqual([(p['id'], p['name'], p['price']) for p in products], expected)
+
+    def test_first_link_wins_for_filled_fields(self):
+        html = ('<a href="/product/a-1/?first=1"><span>Носки мужские 10 пар</span></a>'
+                '<a href="/product/a-1/?second=1"><span>Носки женские 5 пар</span><span>299 ₽</span></a>')
+        [product] = collect_cards(self.links(html))
+        self.assertEqual(product['link'], 'https://www.ozon.ru/product/a-1/?first=1')
+        self.assertEqual(product['name'], 'Носки мужские 10 пар')
+        self.assertEqual(product['price'], '299 ₽')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,39 @@
+"""RateLimiter against a fake clock"""
+import unittest
+
+from ozon_parser import OzonParser, RateLimiter
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self) -> float:
+        return self.now
+
+
+class RateLimiterTest(unittest.TestCase):
+    def test_waits(self):
+        clock = FakeClock()
+        limiter = RateLimiter(60, burst=2, clock=clock)
+        cases = [
+            # (seconds since start, expected wait)
+            (0, 0.0),
+            (0, 0.0),
+            (0, 1.0),
+            (0.5, 1.5),
+            (10, 0.0),
+        ]
+        for at, expected in cases:
+            with self.subTest(at=at):
+                clock.now = at
+                self.assertAlmostEqual(limiter.reserve(), expected)
+
+    def test_parser_passes_its_clock(self):
+        clock = FakeClock()
+        ozon = OzonParser(requests_per_minute=30, clock=clock)
+        self.assertIs(ozon.rate_limiter.clock, clock)
+
+
+if __name__ == '__main__':
+    unittest.main()